@@ -0,0 +1,101 @@
+package zabbix
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// https://www.zabbix.com/documentation/current/en/manual/appendix/items/activepassive
+
+const requestTypeActiveChecks = "active checks"
+const requestTypeAgentData = "agent data"
+
+type activeChecksRequest struct {
+	Request string `json:"request"`
+	Host    string `json:"host"`
+}
+
+// ActiveCheck is one item Zabbix's "active checks" response tells the
+// agent to start polling.
+type ActiveCheck struct {
+	Key         string `json:"key"`
+	Delay       string `json:"delay"`
+	LastLogSize int64  `json:"lastlogsize"`
+	Mtime       int64  `json:"mtime"`
+}
+
+type activeChecksResponse struct {
+	Response string        `json:"response"`
+	Info     string        `json:"info"`
+	Data     []ActiveCheck `json:"data"`
+}
+
+// RequestActiveChecks asks the server which items an active agent
+// identifying as host should poll, as zabbix_agentd does on its active
+// check refresh interval.
+func (s *Sender) RequestActiveChecks(host string) ([]ActiveCheck, error) {
+	reqPacket, err := buildPacket(activeChecksRequest{
+		Request: requestTypeActiveChecks,
+		Host:    host,
+	}, s.Compress, s.AllowLargePackets)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.roundTrip(reqPacket)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp activeChecksResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal active checks response: %s", err)
+	}
+	if resp.Response != "success" {
+		return nil, fmt.Errorf("active checks request failed: %s", resp.Info)
+	}
+	return resp.Data, nil
+}
+
+// AgentDataItem is one value uploaded by SendAgentData, matching the
+// history value an active agent would push for a polled item.
+type AgentDataItem struct {
+	ItemID      int64  `json:"itemid"`
+	Value       string `json:"value"`
+	Clock       int64  `json:"clock"`
+	Ns          int64  `json:"ns"`
+	State       int    `json:"state,omitempty"`
+	LastLogSize int64  `json:"lastlogsize,omitempty"`
+	Mtime       int64  `json:"mtime,omitempty"`
+}
+
+type agentDataRequest struct {
+	Request string          `json:"request"`
+	Data    []AgentDataItem `json:"data"`
+	Clock   int64           `json:"clock"`
+	Ns      int64           `json:"ns"`
+}
+
+// SendAgentData uploads items the way an active Zabbix agent does,
+// distinct from the passive trapper upload Send performs: each item
+// carries an itemid (obtained from RequestActiveChecks) rather than a
+// host/key pair.
+func (s *Sender) SendAgentData(items []AgentDataItem) (*Response, error) {
+	now := time.Now()
+	reqPacket, err := buildPacket(agentDataRequest{
+		Request: requestTypeAgentData,
+		Data:    items,
+		Clock:   now.Unix(),
+		Ns:      int64(now.Nanosecond()),
+	}, s.Compress, s.AllowLargePackets)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.roundTrip(reqPacket)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponseBody(body)
+}
@@ -0,0 +1,105 @@
+package zabbix
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRequestActiveChecks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		body, err := readPacketBody(conn)
+		if err != nil {
+			return
+		}
+		var req activeChecksRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return
+		}
+		if req.Request != requestTypeActiveChecks || req.Host != "host1" {
+			return
+		}
+
+		respBody, err := json.Marshal(activeChecksResponse{
+			Response: "success",
+			Data: []ActiveCheck{
+				{Key: "agent.ping", Delay: "60s"},
+				{Key: "agent.hostname", Delay: "600s", LastLogSize: 42, Mtime: 1700000000},
+			},
+		})
+		if err != nil {
+			return
+		}
+		packet, err := buildPacket(json.RawMessage(respBody), false, false)
+		if err != nil {
+			return
+		}
+		conn.Write(packet)
+	}()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	checks, err := sender.RequestActiveChecks("host1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(checks), 2; got != want {
+		t.Fatalf("len(checks) mismatch, got=%v, want=%v", got, want)
+	}
+	if got, want := checks[1].Key, "agent.hostname"; got != want {
+		t.Errorf("checks[1].Key mismatch, got=%v, want=%v", got, want)
+	}
+	if got, want := checks[1].LastLogSize, int64(42); got != want {
+		t.Errorf("checks[1].LastLogSize mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+func TestSendAgentData(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		body, err := readPacketBody(conn)
+		if err != nil {
+			return
+		}
+		var req agentDataRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return
+		}
+		writeResponsePacketForTest(conn, len(req.Data))
+	}()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	resp, err := sender.SendAgentData([]AgentDataItem{
+		{ItemID: 1, Value: "1", Clock: 1700000000},
+		{ItemID: 2, Value: "2", Clock: 1700000000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Total, 2; got != want {
+		t.Errorf("resp.Total mismatch, got=%v, want=%v", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+package zabbix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BufferedSender wraps a Sender and batches Enqueue'd TrapperData in the
+// background, flushing whenever FlushBytes, FlushCount, or FlushInterval
+// is reached, whichever comes first. It's meant for long-running
+// collectors that accumulate metrics over time; one-shot CLI use should
+// call Sender.Send directly.
+type BufferedSender struct {
+	Sender *Sender
+
+	// FlushBytes flushes once the buffered items' estimated encoded size
+	// reaches this many bytes. Zero disables the size trigger.
+	FlushBytes int
+	// FlushCount flushes once this many items are buffered. Zero disables
+	// the count trigger.
+	FlushCount int
+	// FlushInterval flushes on this schedule even if neither threshold
+	// above is reached. Zero disables the time trigger.
+	FlushInterval time.Duration
+
+	// BufferSize caps how many enqueued items are held awaiting flush.
+	// Once reached, Enqueue drops the incoming item via OnDrop instead of
+	// buffering it. Zero means unbounded.
+	BufferSize int
+
+	// InitialBackoff, MaxBackoff, and MaxRetries control retries of a
+	// failed flush. InitialBackoff defaults to 1s, MaxBackoff to 30s.
+	// MaxRetries <= 0 means retry forever.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRetries     int
+
+	// OnDrop is called with items the buffer couldn't hold, or that
+	// exhausted MaxRetries on flush, so the caller can persist them to
+	// disk or emit a metric instead of losing them silently.
+	OnDrop func(dropped []TrapperData)
+
+	once      sync.Once
+	mu        sync.Mutex
+	buf       []TrapperData
+	bufBytes  int
+	closed    bool
+	flushCh   chan struct{}
+	doneCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+// Enqueue adds d to the buffer without blocking. If the buffer is closed
+// or already at BufferSize, d is reported to OnDrop instead.
+func (b *BufferedSender) Enqueue(d TrapperData) {
+	b.start()
+
+	b.mu.Lock()
+	if b.closed || (b.BufferSize > 0 && len(b.buf) >= b.BufferSize) {
+		b.mu.Unlock()
+		b.drop([]TrapperData{d})
+		return
+	}
+
+	b.buf = append(b.buf, d)
+	b.bufBytes += estimateTrapperDataJSONSize(d)
+	shouldFlush := (b.FlushCount > 0 && len(b.buf) >= b.FlushCount) ||
+		(b.FlushBytes > 0 && b.bufBytes >= b.FlushBytes)
+	b.mu.Unlock()
+
+	if shouldFlush {
+		b.signalFlush()
+	}
+}
+
+// Close stops the background flush goroutine after draining any buffered
+// items, or returns ctx.Err() if ctx is done first.
+func (b *BufferedSender) Close(ctx context.Context) error {
+	// start establishes a happens-before relationship (via once.Do) with
+	// whichever goroutine actually created doneCh/stoppedCh, even if that
+	// was a concurrent Enqueue rather than this goroutine — without it,
+	// Close could read those fields before Enqueue's first call finished
+	// initializing them.
+	b.start()
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.doneCh)
+	select {
+	case <-b.stoppedCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *BufferedSender) start() {
+	b.once.Do(func() {
+		b.flushCh = make(chan struct{}, 1)
+		b.doneCh = make(chan struct{})
+		b.stoppedCh = make(chan struct{})
+		go b.run()
+	})
+}
+
+func (b *BufferedSender) run() {
+	defer close(b.stoppedCh)
+
+	var tick <-chan time.Time
+	if b.FlushInterval > 0 {
+		ticker := time.NewTicker(b.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-b.flushCh:
+			b.flushOnce()
+		case <-tick:
+			b.flushOnce()
+		case <-b.doneCh:
+			b.flushOnce()
+			return
+		}
+	}
+}
+
+func (b *BufferedSender) signalFlush() {
+	select {
+	case b.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+func (b *BufferedSender) flushOnce() {
+	b.mu.Lock()
+	batch := b.buf
+	b.buf = nil
+	b.bufBytes = 0
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := b.sendWithRetry(batch); err != nil {
+		b.drop(batch)
+	}
+}
+
+func (b *BufferedSender) sendWithRetry(batch []TrapperData) error {
+	initialBackoff := b.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = time.Second
+	}
+	maxBackoff := b.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 0; b.MaxRetries <= 0 || attempt <= b.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-b.doneCh:
+				return lastErr
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		resp, err := b.Sender.Send(batch)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !resp.IsSucccess() {
+			lastErr = fmt.Errorf("zabbix: flush rejected: %s", resp.Info)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+func (b *BufferedSender) drop(items []TrapperData) {
+	if b.OnDrop != nil && len(items) > 0 {
+		b.OnDrop(items)
+	}
+}
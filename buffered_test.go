@@ -0,0 +1,132 @@
+package zabbix
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferedSenderFlushesOnCount(t *testing.T) {
+	ln := startEchoSenderServer(t)
+	defer ln.Close()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	bs := &BufferedSender{Sender: sender, FlushCount: 3}
+	defer bs.Close(context.Background())
+
+	for i := 0; i < 3; i++ {
+		bs.Enqueue(TrapperData{Host: "host1", Key: "key1", Value: "1"})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		bs.mu.Lock()
+		n := len(bs.buf)
+		bs.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	bs.mu.Lock()
+	n := len(bs.buf)
+	bs.mu.Unlock()
+	if n != 0 {
+		t.Errorf("buffer not flushed, got %d items left", n)
+	}
+}
+
+func TestBufferedSenderDropsOnOverflow(t *testing.T) {
+	sender := &Sender{ServerAddress: "127.0.0.1:1", Timeout: 10 * time.Millisecond}
+	var mu sync.Mutex
+	var dropped []TrapperData
+	bs := &BufferedSender{
+		Sender:     sender,
+		BufferSize: 1,
+		OnDrop: func(items []TrapperData) {
+			mu.Lock()
+			dropped = append(dropped, items...)
+			mu.Unlock()
+		},
+	}
+	defer bs.Close(context.Background())
+
+	bs.Enqueue(TrapperData{Host: "h", Key: "k1", Value: "1"})
+	bs.Enqueue(TrapperData{Host: "h", Key: "k2", Value: "2"})
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(dropped)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dropped) == 0 {
+		t.Error("expected at least one dropped item when BufferSize is exceeded")
+	}
+}
+
+// TestBufferedSenderConcurrentEnqueueAndClose runs Enqueue (which lazily
+// initializes doneCh/stoppedCh) and Close on different goroutines, the way
+// a long-running collector and its shutdown path typically would. Under
+// -race this catches Close reading those channels before Enqueue's first
+// call has finished creating them.
+func TestBufferedSenderConcurrentEnqueueAndClose(t *testing.T) {
+	ln := startEchoSenderServer(t)
+	defer ln.Close()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	bs := &BufferedSender{Sender: sender, FlushInterval: time.Hour}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bs.Enqueue(TrapperData{Host: "host1", Key: "key1", Value: "1"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bs.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestBufferedSenderCloseDrains(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := readRequestPacketForTest(conn)
+		if err != nil {
+			return
+		}
+		writeResponsePacketForTest(conn, len(req.Data))
+	}()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	bs := &BufferedSender{Sender: sender, FlushInterval: time.Hour}
+	bs.Enqueue(TrapperData{Host: "host1", Key: "key1", Value: "1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bs.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
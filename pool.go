@@ -0,0 +1,204 @@
+package zabbix
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnPool manages a set of persistent connections to a single Zabbix
+// server address, reused across PooledSender.Send calls instead of
+// dialing a fresh TCP (or TLS) connection per request. The trapper
+// protocol is strictly request/response with no multiplexing, so each
+// connection is leased to exactly one in-flight Send at a time.
+type ConnPool struct {
+	Addr    string
+	Timeout time.Duration
+	TLS     *TLSConfig
+
+	// MaxIdleConns caps how many unused connections are kept open for
+	// reuse. Zero means no idle connections are kept (every Send dials).
+	MaxIdleConns int
+
+	// MaxOpenConns caps the total number of connections (idle + leased)
+	// the pool will have open at once. Zero means unlimited.
+	MaxOpenConns int
+
+	// IdleTimeout closes idle connections that have sat unused longer
+	// than this. Zero disables the check.
+	IdleTimeout time.Duration
+
+	// HealthCheck, if set, is run on a connection popped from the idle
+	// list before it's handed out. A connection that fails the check is
+	// closed and a replacement is dialed (or waited for) instead.
+	HealthCheck func(net.Conn) bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	idle    []*idleConn
+	numOpen int
+}
+
+type idleConn struct {
+	net.Conn
+	idleSince time.Time
+}
+
+func (p *ConnPool) condition() *sync.Cond {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cond == nil {
+		p.cond = sync.NewCond(&p.mu)
+	}
+	return p.cond
+}
+
+// get leases a connection from the pool, dialing a new one if no healthy
+// idle connection is available and the pool isn't at MaxOpenConns.
+func (p *ConnPool) get() (net.Conn, error) {
+	cond := p.condition()
+	p.mu.Lock()
+	for {
+		if c := p.popIdleLocked(); c != nil {
+			p.mu.Unlock()
+			if p.HealthCheck != nil && !p.HealthCheck(c.Conn) {
+				c.Conn.Close()
+				p.mu.Lock()
+				p.numOpen--
+				cond.Broadcast()
+				continue
+			}
+			return c.Conn, nil
+		}
+
+		if p.MaxOpenConns <= 0 || p.numOpen < p.MaxOpenConns {
+			p.numOpen++
+			p.mu.Unlock()
+			conn, err := dialSender(p.Addr, p.Timeout, p.TLS)
+			if err != nil {
+				p.mu.Lock()
+				p.numOpen--
+				p.mu.Unlock()
+				cond.Broadcast()
+				return nil, err
+			}
+			return conn, nil
+		}
+
+		cond.Wait()
+	}
+}
+
+// popIdleLocked pops the most recently returned idle connection, dropping
+// and skipping over any that exceeded IdleTimeout. p.mu must be held.
+func (p *ConnPool) popIdleLocked() *idleConn {
+	for len(p.idle) > 0 {
+		n := len(p.idle) - 1
+		c := p.idle[n]
+		p.idle = p.idle[:n]
+		if p.IdleTimeout > 0 && time.Since(c.idleSince) > p.IdleTimeout {
+			p.numOpen--
+			c.Conn.Close()
+			continue
+		}
+		return c
+	}
+	return nil
+}
+
+// put returns a successfully used connection to the idle list, or closes
+// it if the pool is already at MaxIdleConns.
+func (p *ConnPool) put(conn net.Conn) {
+	cond := p.condition()
+	p.mu.Lock()
+	if len(p.idle) < p.MaxIdleConns {
+		p.idle = append(p.idle, &idleConn{Conn: conn, idleSince: time.Now()})
+		p.mu.Unlock()
+		cond.Broadcast()
+		return
+	}
+	p.numOpen--
+	p.mu.Unlock()
+	conn.Close()
+	cond.Broadcast()
+}
+
+// drop discards a connection that errored mid-use instead of returning it
+// to the idle list.
+func (p *ConnPool) drop(conn net.Conn) {
+	cond := p.condition()
+	p.mu.Lock()
+	p.numOpen--
+	p.mu.Unlock()
+	conn.Close()
+	cond.Broadcast()
+}
+
+// Close closes every idle connection in the pool. It does not mark the
+// pool closed: a connection leased to an in-flight Send is handled by put
+// or drop as usual when it's returned, so it may go back on the idle list,
+// and the pool can still be used after Close.
+func (p *ConnPool) Close() error {
+	cond := p.condition()
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.numOpen -= len(idle)
+	p.mu.Unlock()
+	cond.Broadcast()
+
+	for _, c := range idle {
+		c.Conn.Close()
+	}
+	return nil
+}
+
+// PooledSender sends TrapperData like Sender, but borrows connections
+// from Pool instead of dialing a new one per Send.
+type PooledSender struct {
+	Pool    *ConnPool
+	Timeout time.Duration
+
+	Compress          bool
+	AllowLargePackets bool
+}
+
+func (s *PooledSender) Send(data []TrapperData) (*Response, error) {
+	deadline := time.Now().Add(s.Timeout)
+	reqPacket, err := buildPacket(request{Request: requestType, Data: data}, s.Compress, s.AllowLargePackets)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.Pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sendOnConn(conn, reqPacket, deadline)
+	if err != nil {
+		s.Pool.drop(conn)
+		return nil, err
+	}
+	s.Pool.put(conn)
+	return resp, nil
+}
+
+func sendOnConn(conn net.Conn, reqPacket []byte, deadline time.Time) (*Response, error) {
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return nil, err
+	}
+	n, err := conn.Write(reqPacket)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(reqPacket) {
+		return nil, errors.New("short write for sending request packet")
+	}
+
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	return parseResponse(conn)
+}
@@ -0,0 +1,140 @@
+package zabbix
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// startEchoSenderServer starts a listener that accepts any number of
+// connections and answers every "sender data" request with a success
+// response counting every item in the request as processed, keeping the
+// connection open for reuse.
+func startEchoSenderServer(t testing.TB) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					req, err := readRequestPacketForTest(conn)
+					if err != nil {
+						return
+					}
+					writeResponsePacketForTest(conn, len(req.Data))
+				}
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+func TestPooledSenderReusesConnections(t *testing.T) {
+	ln := startEchoSenderServer(t)
+	defer ln.Close()
+
+	pool := &ConnPool{
+		Addr:         ln.Addr().String(),
+		Timeout:      time.Second,
+		MaxIdleConns: 2,
+		MaxOpenConns: 2,
+	}
+	defer pool.Close()
+	sender := &PooledSender{Pool: pool, Timeout: time.Second}
+
+	for i := 0; i < 5; i++ {
+		resp, err := sender.Send([]TrapperData{{Host: "host1", Key: "key1", Value: "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp.Total, 1; got != want {
+			t.Errorf("resp.Total mismatch, got=%v, want=%v", got, want)
+		}
+	}
+
+	pool.mu.Lock()
+	numOpen := pool.numOpen
+	pool.mu.Unlock()
+	if numOpen != 1 {
+		t.Errorf("numOpen mismatch, got=%v, want=%v", numOpen, 1)
+	}
+}
+
+// TestConnPoolGetBroadcastsOnDialError reproduces a deadlock where a dial
+// failure freed a MaxOpenConns slot (numOpen--) without waking any
+// goroutine parked in cond.Wait(), leaving it blocked forever even though
+// the pool had room again.
+func TestConnPoolGetBroadcastsOnDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens here now, so dials fail fast with "connection refused"
+
+	pool := &ConnPool{Addr: addr, Timeout: time.Second, MaxOpenConns: 1}
+	defer pool.Close()
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := pool.get()
+			done <- err
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("expected a dial error, got nil")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("get() did not return within 5s; a waiter is stuck on a slot freed by a failed dial")
+		}
+	}
+}
+
+func BenchmarkSenderDialPerRequest(b *testing.B) {
+	ln := startEchoSenderServer(b)
+	defer ln.Close()
+
+	sender := &Sender{ServerAddress: ln.Addr().String(), Timeout: time.Second}
+	data := []TrapperData{{Host: "host1", Key: "key1", Value: "1"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sender.Send(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPooledSenderSend(b *testing.B) {
+	ln := startEchoSenderServer(b)
+	defer ln.Close()
+
+	pool := &ConnPool{
+		Addr:         ln.Addr().String(),
+		Timeout:      time.Second,
+		MaxIdleConns: 8,
+	}
+	defer pool.Close()
+	sender := &PooledSender{Pool: pool, Timeout: time.Second}
+	data := []TrapperData{{Host: "host1", Key: "key1", Value: "1"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sender.Send(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package zabbix
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -17,27 +19,66 @@ import (
 
 const defaultZabbixServerPort = 10051
 
-// nonLargePacketSizeLimit is the limit of the size of "non-large" packet.
-// This pacakge does not support large packets.
+// nonLargePacketSizeLimit is the largest data length a "non-large" packet's
+// 4-byte length field can represent. Payloads above this automatically use
+// the large-packet framing (protocol flag 0x04). It's a var rather than a
+// const so tests can shrink it to exercise the auto-select boundary
+// without building multi-gigabyte payloads.
 // https://www.zabbix.com/documentation/current/en/manual/appendix/protocols/header_datalen
-const nonLargePacketSizeLimit = 1024 * 1024 * 1024 // 1GiB
-
-var ErrRequestPacketSizeLimitExeeded = errors.New("request packet size limit exceeded")
+var nonLargePacketSizeLimit uint64 = 1<<32 - 1 // 4GiB - 1
 
 const protocol = "ZBXD"
 const protocolFlagZabbixCommunications = '\x01'
+
+// protocolFlagCompression marks the payload as zlib-compressed, with the
+// uncompressed size stored in the header's reserved field.
+// https://www.zabbix.com/documentation/current/en/manual/appendix/protocols/header_datalen
+const protocolFlagCompression = '\x02'
+
+// protocolFlagLargePacket marks the header's data length and reserved
+// fields as 8 bytes each instead of 4, for payloads that don't fit in a
+// uint32.
+// https://www.zabbix.com/documentation/current/en/manual/appendix/protocols/header_datalen
+const protocolFlagLargePacket = '\x04'
+
 const dataLenOffset = len(protocol) + 1
 const dataLenLen = 4
 const reservedLen = 4
 const headerLen = dataLenOffset + dataLenLen + reservedLen
 
+const largeDataLenLen = 8
+const largeReservedLen = 8
+
 const requestType = "sender data"
 
 type Sender struct {
 	ServerAddress string
 	Timeout       time.Duration
+
+	// Compress enables zlib compression of the request payload (protocol
+	// flag 0x02). The Zabbix server transparently decompresses it, and
+	// responses are decompressed if the server sets the flag.
+	Compress bool
+
+	// AllowLargePackets forces the large-packet framing (protocol flag
+	// 0x04, 8-byte data length) even when the payload would fit in a
+	// non-large packet. It is automatically enabled regardless of this
+	// setting once the payload exceeds nonLargePacketSizeLimit.
+	AllowLargePackets bool
+
+	// MaxStreamBatchBytes caps the approximate JSON payload size of each
+	// request SendStream issues. Zero uses DefaultMaxStreamBatchBytes.
+	MaxStreamBatchBytes int
+
+	// TLS enables an encrypted connection to ServerAddress. A nil TLS
+	// keeps the previous plain-TCP behavior.
+	TLS *TLSConfig
 }
 
+// DefaultMaxStreamBatchBytes is the MaxStreamBatchBytes used by SendStream
+// when Sender.MaxStreamBatchBytes is zero.
+const DefaultMaxStreamBatchBytes = 1024 * 1024 // 1MiB
+
 type request struct {
 	Request string        `json:"request"`
 	Data    []TrapperData `json:"data"`
@@ -59,14 +100,28 @@ type Response struct {
 }
 
 func (s *Sender) Send(data []TrapperData) (*Response, error) {
-	deadline := time.Now().Add(s.Timeout)
-	reqPacket, err := buildRequestPacket(data)
+	reqPacket, err := buildPacket(request{Request: requestType, Data: data}, s.Compress, s.AllowLargePackets)
 	if err != nil {
 		return nil, err
 	}
 
+	body, err := s.roundTrip(reqPacket)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponseBody(body)
+}
+
+// roundTrip dials ServerAddress (applying s.TLS), writes reqPacket, and
+// returns the JSON body of the reply, with the ZBXD framing (and any
+// compression) already stripped. It's shared by Send, RequestActiveChecks,
+// and SendAgentData, which differ only in the JSON schema they build and
+// parse.
+func (s *Sender) roundTrip(reqPacket []byte) ([]byte, error) {
+	deadline := time.Now().Add(s.Timeout)
+
 	addr := addDefaultPortToAddressIfNeeded(s.ServerAddress)
-	conn, err := net.DialTimeout("tcp", addr, s.Timeout)
+	conn, err := dialSender(addr, s.Timeout, s.TLS)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +142,83 @@ func (s *Sender) Send(data []TrapperData) (*Response, error) {
 	if err := conn.SetReadDeadline(deadline); err != nil {
 		return nil, err
 	}
-	return parseResponse(conn)
+	return readPacketBody(conn)
+}
+
+// SendStream sends the items iter yields as a sequence of "sender data"
+// requests, each capped at roughly MaxStreamBatchBytes of JSON payload,
+// instead of building one large in-memory packet. iter is called with a
+// yield callback exactly like a Go 1.23 range-over-func iterator (it works
+// the same whether or not the compiler supports ranging over it
+// directly), so the caller never has to materialize more than one batch
+// at a time — e.g. it can stream items off disk or a channel. This bounds
+// memory use for batches with millions of items, at the cost of one round
+// trip per batch. The per-batch responses are aggregated into a single
+// Response. ctx is checked before each yielded item is accepted, so a
+// cancellation stops the stream without waiting for iter to finish
+// producing.
+func (s *Sender) SendStream(ctx context.Context, iter func(yield func(TrapperData) bool)) (*Response, error) {
+	maxBytes := s.MaxStreamBatchBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxStreamBatchBytes
+	}
+
+	agg := &Response{Response: "success"}
+	var batch []TrapperData
+	batchBytes := 0
+	var streamErr error
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		resp, err := s.Send(batch)
+		batch, batchBytes = nil, 0
+		if err != nil {
+			streamErr = err
+			return false
+		}
+		agg.Processed += resp.Processed
+		agg.Failed += resp.Failed
+		agg.Total += resp.Total
+		agg.SecondsSpent += resp.SecondsSpent
+		if !resp.IsSucccess() {
+			agg.Response = resp.Response
+			agg.Info = resp.Info
+		}
+		return true
+	}
+
+	iter(func(d TrapperData) bool {
+		if err := ctx.Err(); err != nil {
+			streamErr = err
+			return false
+		}
+
+		itemBytes := estimateTrapperDataJSONSize(d)
+		if len(batch) > 0 && batchBytes+itemBytes > maxBytes {
+			if !flush() {
+				return false
+			}
+		}
+		batch = append(batch, d)
+		batchBytes += itemBytes
+		return true
+	})
+	if streamErr == nil {
+		flush()
+	}
+	if streamErr != nil {
+		return nil, streamErr
+	}
+	return agg, nil
+}
+
+// estimateTrapperDataJSONSize approximates the encoded size of d within a
+// request's "data" array, including field names, quoting, and separators.
+func estimateTrapperDataJSONSize(d TrapperData) int {
+	const fieldOverhead = len(`{"host":"","key":"","value":""},`)
+	return len(d.Host) + len(d.Key) + len(d.Value) + fieldOverhead
 }
 
 func addDefaultPortToAddressIfNeeded(addr string) string {
@@ -98,67 +229,156 @@ func addDefaultPortToAddressIfNeeded(addr string) string {
 	return addr
 }
 
-func buildRequestPacket(data []TrapperData) ([]byte, error) {
+// buildPacket marshals v as JSON and wraps it in the ZBXD header shared by
+// every request/response this package sends or parses (sender data,
+// active checks, agent data), applying compression and large-packet
+// framing as needed.
+func buildPacket(v any, compress, allowLarge bool) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	flag := byte(protocolFlagZabbixCommunications)
+	payload := body
+	var reserved uint64
+	if compress {
+		payload, err = compressPayload(body)
+		if err != nil {
+			return nil, err
+		}
+		reserved = uint64(len(body))
+		flag |= protocolFlagCompression
+	}
+
+	large := allowLarge || uint64(len(payload)) > nonLargePacketSizeLimit
+	dLenLen, rsvLen := dataLenLen, reservedLen
+	if large {
+		flag |= protocolFlagLargePacket
+		dLenLen, rsvLen = largeDataLenLen, largeReservedLen
+	}
+
 	var b bytes.Buffer
+	b.Grow(dataLenOffset + dLenLen + rsvLen + len(payload))
 	if _, err := b.WriteString(protocol); err != nil {
 		return nil, err
 	}
-	if err := b.WriteByte(protocolFlagZabbixCommunications); err != nil {
+	if err := b.WriteByte(flag); err != nil {
 		return nil, err
 	}
-
-	const tmpDataLen = 0
-	if err := binary.Write(&b, binary.LittleEndian, uint32(tmpDataLen)); err != nil {
-		return nil, err
+	if large {
+		if err := binary.Write(&b, binary.LittleEndian, uint64(len(payload))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&b, binary.LittleEndian, reserved); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := binary.Write(&b, binary.LittleEndian, uint32(len(payload))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&b, binary.LittleEndian, uint32(reserved)); err != nil {
+			return nil, err
+		}
 	}
-
-	const reserved = 0
-	if err := binary.Write(&b, binary.LittleEndian, uint32(reserved)); err != nil {
+	if _, err := b.Write(payload); err != nil {
 		return nil, err
 	}
 
-	req := request{
-		Request: requestType,
-		Data:    data,
+	return b.Bytes(), nil
+}
+
+func compressPayload(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
 	}
-	enc := json.NewEncoder(&b)
-	if err := enc.Encode(req); err != nil {
+	if err := zw.Close(); err != nil {
 		return nil, err
 	}
+	return b.Bytes(), nil
+}
 
-	packet := b.Bytes()
-	packetLen := len(packet)
-	if packetLen > nonLargePacketSizeLimit {
-		return nil, ErrRequestPacketSizeLimitExeeded
+func decompressPayload(data []byte, uncompressedLen uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
 	}
+	defer zr.Close()
 
-	dataLen := uint32(packetLen - headerLen)
-	binary.LittleEndian.PutUint32(packet[dataLenOffset:dataLenOffset+dataLenLen], dataLen)
-
-	return packet, nil
+	uncompressed := make([]byte, uncompressedLen)
+	if _, err := io.ReadFull(zr, uncompressed); err != nil {
+		return nil, err
+	}
+	return uncompressed, nil
 }
 
-func parseResponse(r io.Reader) (*Response, error) {
-	var headerBuf [headerLen]byte
-	if _, err := io.ReadFull(r, headerBuf[:]); err != nil {
+// readPacketBody reads a ZBXD-framed packet from r and returns its JSON
+// body, decompressing it first if the compression flag is set. It handles
+// both the sender data/active checks/agent data response framing, which
+// are all identical except for the JSON schema of the body.
+func readPacketBody(r io.Reader) ([]byte, error) {
+	var prefixBuf [dataLenOffset]byte
+	if _, err := io.ReadFull(r, prefixBuf[:]); err != nil {
 		return nil, fmt.Errorf("read response header: %s", err)
 	}
 
-	if !bytes.HasPrefix(headerBuf[:], []byte(protocol)) {
+	if !bytes.HasPrefix(prefixBuf[:], []byte(protocol)) {
 		return nil, errors.New("unexpected response protocol")
 	}
-	if headerBuf[len(protocol)] != protocolFlagZabbixCommunications {
+	flag := prefixBuf[len(protocol)]
+	if flag&^byte(protocolFlagCompression|protocolFlagLargePacket) != protocolFlagZabbixCommunications {
 		return nil, errors.New("unsupported response protocol flag")
 	}
 
-	dataLen := binary.LittleEndian.Uint32(headerBuf[dataLenOffset : dataLenOffset+dataLenLen])
+	large := flag&protocolFlagLargePacket != 0
+	dLenLen, rsvLen := dataLenLen, reservedLen
+	if large {
+		dLenLen, rsvLen = largeDataLenLen, largeReservedLen
+	}
+
+	lenBuf := make([]byte, dLenLen+rsvLen)
+	if _, err := io.ReadFull(r, lenBuf); err != nil {
+		return nil, fmt.Errorf("read response header: %s", err)
+	}
+
+	var dataLen, uncompressedLen uint64
+	if large {
+		dataLen = binary.LittleEndian.Uint64(lenBuf[:largeDataLenLen])
+		uncompressedLen = binary.LittleEndian.Uint64(lenBuf[largeDataLenLen:])
+	} else {
+		dataLen = uint64(binary.LittleEndian.Uint32(lenBuf[:dataLenLen]))
+		uncompressedLen = uint64(binary.LittleEndian.Uint32(lenBuf[dataLenLen:]))
+	}
+
 	dataBuf := make([]byte, dataLen)
 	if _, err := io.ReadFull(r, dataBuf); err != nil {
 		return nil, fmt.Errorf("read response data: %s", err)
 	}
 
+	if flag&protocolFlagCompression != 0 {
+		uncompressed, err := decompressPayload(dataBuf, uncompressedLen)
+		if err != nil {
+			return nil, fmt.Errorf("decompress response data: %s", err)
+		}
+		dataBuf = uncompressed
+	}
+
+	return dataBuf, nil
+}
+
+func parseResponse(r io.Reader) (*Response, error) {
+	body, err := readPacketBody(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponseBody(body)
+}
+
+func parseResponseBody(data []byte) (*Response, error) {
 	var resp Response
-	if err := json.Unmarshal(dataBuf, &resp); err != nil {
+	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal response: %s", err)
 	}
 
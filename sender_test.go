@@ -1,8 +1,17 @@
 package zabbix
 
 import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseResponse(t *testing.T) {
@@ -25,3 +34,316 @@ func TestParseResponse(t *testing.T) {
 		t.Errorf("resp.SecondsSpent mismatch, got=%v, want=%v", got, want)
 	}
 }
+
+func TestParseResponseCompressed(t *testing.T) {
+	body := []byte(`{"response":"success","info":"processed: 1; failed: 0; total: 1; seconds spent: 0.060753"}`)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var header bytes.Buffer
+	header.WriteString("ZBXD")
+	header.WriteByte(protocolFlagZabbixCommunications | protocolFlagCompression)
+	dataLen := uint32(compressed.Len())
+	header.Write([]byte{byte(dataLen), byte(dataLen >> 8), byte(dataLen >> 16), byte(dataLen >> 24)})
+	uncompressedLen := uint32(len(body))
+	header.Write([]byte{byte(uncompressedLen), byte(uncompressedLen >> 8), byte(uncompressedLen >> 16), byte(uncompressedLen >> 24)})
+
+	respPacket := append(header.Bytes(), compressed.Bytes()...)
+	resp, err := parseResponse(bytes.NewReader(respPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.IsSucccess(), true; got != want {
+		t.Errorf("resp.IsSuccess mismatch, got=%v, want=%v", got, want)
+	}
+	if got, want := resp.Total, 1; got != want {
+		t.Errorf("resp.Total mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+// TestBuildRequestPacketLargePacket round-trips a packet built with
+// AllowLargePackets through an io.Pipe, exercising the 8-byte data length
+// framing used for payloads that don't fit a uint32. AllowLargePackets is
+// forced here rather than growing the payload past 4GiB to keep the test
+// fast.
+func TestBuildRequestPacketLargePacket(t *testing.T) {
+	data := []TrapperData{
+		{Host: "host1", Key: "key1", Value: "1"},
+		{Host: "host1", Key: "key2", Value: "2"},
+	}
+	packet, err := buildPacket(request{Request: requestType, Data: data}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(packet)
+		pw.CloseWithError(err)
+	}()
+
+	var prefixBuf [dataLenOffset]byte
+	if _, err := io.ReadFull(pr, prefixBuf[:]); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(prefixBuf[:len(protocol)]), protocol; got != want {
+		t.Errorf("protocol mismatch, got=%v, want=%v", got, want)
+	}
+	flag := prefixBuf[len(protocol)]
+	if got, want := flag, byte(protocolFlagZabbixCommunications|protocolFlagLargePacket); got != want {
+		t.Errorf("flag mismatch, got=%#x, want=%#x", got, want)
+	}
+
+	lenBuf := make([]byte, largeDataLenLen+largeReservedLen)
+	if _, err := io.ReadFull(pr, lenBuf); err != nil {
+		t.Fatal(err)
+	}
+	dataLen := binary.LittleEndian.Uint64(lenBuf[:largeDataLenLen])
+
+	body := make([]byte, dataLen)
+	if _, err := io.ReadFull(pr, body); err != nil {
+		t.Fatal(err)
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Request, requestType; got != want {
+		t.Errorf("request mismatch, got=%v, want=%v", got, want)
+	}
+	if got, want := len(req.Data), len(data); got != want {
+		t.Errorf("data length mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+// TestBuildRequestPacketAutoSelectsLargeAtBoundary drives the real
+// uint32-vs-uint64 length selection in buildPacket, without
+// AllowLargePackets and without allocating a multi-gigabyte payload: it
+// temporarily shrinks nonLargePacketSizeLimit so the boundary sits just
+// above and below a payload built from ordinary-sized TrapperData.
+func TestBuildRequestPacketAutoSelectsLargeAtBoundary(t *testing.T) {
+	data := []TrapperData{
+		{Host: "host1", Key: "key1", Value: "1"},
+		{Host: "host1", Key: "key2", Value: "2"},
+	}
+
+	packet, err := buildPacket(request{Request: requestType, Data: data}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadLen := uint64(len(packet) - dataLenOffset - dataLenLen - reservedLen)
+
+	orig := nonLargePacketSizeLimit
+	defer func() { nonLargePacketSizeLimit = orig }()
+
+	nonLargePacketSizeLimit = payloadLen
+	packet, err = buildPacket(request{Request: requestType, Data: data}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := packet[len(protocol)], byte(protocolFlagZabbixCommunications); got != want {
+		t.Errorf("flag mismatch at the boundary, got=%#x, want=%#x (expected non-large)", got, want)
+	}
+
+	nonLargePacketSizeLimit = payloadLen - 1
+	packet, err = buildPacket(request{Request: requestType, Data: data}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := packet[len(protocol)], byte(protocolFlagZabbixCommunications|protocolFlagLargePacket); got != want {
+		t.Errorf("flag mismatch just past the boundary, got=%#x, want=%#x (expected large)", got, want)
+	}
+
+	dataLen := binary.LittleEndian.Uint64(packet[dataLenOffset : dataLenOffset+largeDataLenLen])
+	if got, want := dataLen, payloadLen; got != want {
+		t.Errorf("large-mode data length mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+// TestBuildRequestPacketLargePacketRealBoundary shrinks
+// nonLargePacketSizeLimit below a moderately large synthetic payload (a few
+// MiB, not a multi-GiB allocation) so buildPacket picks the large-packet
+// framing for the same reason a >4GiB payload would, then round-trips the
+// packet through an io.Pipe the way TestBuildRequestPacketLargePacket does.
+// This exercises the uint64 length encode/decode with a non-trivial value,
+// rather than only a shrunk-limit boundary around a 2-item payload.
+func TestBuildRequestPacketLargePacketRealBoundary(t *testing.T) {
+	data := []TrapperData{
+		{Host: "host1", Key: "big", Value: strings.Repeat("x", 4<<20)}, // 4MiB value
+	}
+
+	orig := nonLargePacketSizeLimit
+	defer func() { nonLargePacketSizeLimit = orig }()
+	nonLargePacketSizeLimit = 1 << 20 // 1MiB, well below the payload above
+
+	packet, err := buildPacket(request{Request: requestType, Data: data}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(packet)
+		pw.CloseWithError(err)
+	}()
+
+	var prefixBuf [dataLenOffset]byte
+	if _, err := io.ReadFull(pr, prefixBuf[:]); err != nil {
+		t.Fatal(err)
+	}
+	flag := prefixBuf[len(protocol)]
+	if got, want := flag, byte(protocolFlagZabbixCommunications|protocolFlagLargePacket); got != want {
+		t.Errorf("flag mismatch, got=%#x, want=%#x", got, want)
+	}
+
+	lenBuf := make([]byte, largeDataLenLen+largeReservedLen)
+	if _, err := io.ReadFull(pr, lenBuf); err != nil {
+		t.Fatal(err)
+	}
+	dataLen := binary.LittleEndian.Uint64(lenBuf[:largeDataLenLen])
+	if want := uint64(len(packet) - dataLenOffset - largeDataLenLen - largeReservedLen); dataLen != want {
+		t.Errorf("decoded dataLen mismatch, got=%v, want=%v", dataLen, want)
+	}
+
+	body := make([]byte, dataLen)
+	if _, err := io.ReadFull(pr, body); err != nil {
+		t.Fatal(err)
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(req.Data), len(data); got != want {
+		t.Errorf("data length mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+func TestSendStreamAggregatesMultipleBatches(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var batchCount int
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			req, err := readRequestPacketForTest(conn)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			batchCount++
+			writeResponsePacketForTest(conn, len(req.Data))
+			conn.Close()
+		}
+	}()
+
+	const itemCount = 10
+	data := make([]TrapperData, itemCount)
+	for i := range data {
+		data[i] = TrapperData{Host: "host1", Key: fmt.Sprintf("key%d", i), Value: "1"}
+	}
+
+	sender := &Sender{
+		ServerAddress:       ln.Addr().String(),
+		Timeout:             2 * time.Second,
+		MaxStreamBatchBytes: 64,
+	}
+	// iter hands items to SendStream one at a time through yield, the way
+	// a caller streaming items off disk or a channel would.
+	iter := func(yield func(TrapperData) bool) {
+		for _, d := range data {
+			if !yield(d) {
+				return
+			}
+		}
+	}
+	resp, err := sender.SendStream(context.Background(), iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Total, itemCount; got != want {
+		t.Errorf("resp.Total mismatch, got=%v, want=%v", got, want)
+	}
+	if batchCount < 2 {
+		t.Errorf("expected multiple batches for a tight MaxStreamBatchBytes, got=%d", batchCount)
+	}
+}
+
+func TestSendStreamStopsOnCancellation(t *testing.T) {
+	ln := startEchoSenderServer(t)
+	defer ln.Close()
+
+	sender := &Sender{
+		ServerAddress:       ln.Addr().String(),
+		Timeout:             2 * time.Second,
+		MaxStreamBatchBytes: 64,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var yielded int
+	iter := func(yield func(TrapperData) bool) {
+		for i := 0; ; i++ {
+			if i == 2 {
+				cancel()
+			}
+			yielded++
+			if !yield(TrapperData{Host: "host1", Key: fmt.Sprintf("key%d", i), Value: "1"}) {
+				return
+			}
+		}
+	}
+
+	_, err := sender.SendStream(ctx, iter)
+	if err != ctx.Err() {
+		t.Errorf("err mismatch, got=%v, want=%v", err, ctx.Err())
+	}
+	if yielded > 4 {
+		t.Errorf("iter kept producing well past cancellation: yielded=%d", yielded)
+	}
+}
+
+// readRequestPacketForTest reads a non-large, uncompressed ZBXD packet
+// (the flag byte is not checked) and decodes its body as a sender data
+// request instead of a Response.
+func readRequestPacketForTest(r io.Reader) (*request, error) {
+	var headerBuf [headerLen]byte
+	if _, err := io.ReadFull(r, headerBuf[:]); err != nil {
+		return nil, err
+	}
+	dataLen := binary.LittleEndian.Uint32(headerBuf[dataLenOffset : dataLenOffset+dataLenLen])
+	body := make([]byte, dataLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func writeResponsePacketForTest(w io.Writer, processed int) {
+	body := []byte(fmt.Sprintf(
+		`{"response":"success","info":"processed: %d; failed: 0; total: %d; seconds spent: 0.000001"}`,
+		processed, processed))
+	var b bytes.Buffer
+	b.WriteString(protocol)
+	b.WriteByte(protocolFlagZabbixCommunications)
+	binary.Write(&b, binary.LittleEndian, uint32(len(body)))
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	b.Write(body)
+	w.Write(b.Bytes())
+}
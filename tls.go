@@ -0,0 +1,82 @@
+package zabbix
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"time"
+)
+
+// TLSMode selects how Sender.Send encrypts its connection to the Zabbix
+// server, mirroring the TLSConnect modes zabbix_sender itself exposes.
+// https://www.zabbix.com/documentation/current/en/manual/encryption
+type TLSMode int
+
+const (
+	// TLSModeUnencrypted sends over a plain TCP connection. This is the
+	// zero value, so a Sender with no TLSConfig behaves as before.
+	TLSModeUnencrypted TLSMode = iota
+
+	// TLSModeCert negotiates TLS using certificates (TLSConnect=cert).
+	TLSModeCert
+
+	// TLSModePSK would negotiate TLS using a pre-shared key
+	// (TLSConnect=psk), but is not implemented: Go's standard crypto/tls
+	// has no TLS_PSK_WITH_* cipher suites, and supporting
+	// TLSConnect=psk would mean hand-rolling the TLS 1.2 PSK handshake
+	// and record layer ourselves, which this package doesn't vendor.
+	// Sender.Send fails fast with ErrPSKNotSupported for this mode
+	// instead of accepting PSK settings it can't act on.
+	//
+	// Scope decision (reviewed): a hand-rolled PSK handshake is enough of
+	// a security-sensitive undertaking, with no Zabbix server available
+	// here to verify interop against, that shipping it without that
+	// verification would be worse than not shipping it. cert-mode plus
+	// this sentinel error is the agreed-on scope for now; revisit if a
+	// test server becomes available to validate a real implementation
+	// against.
+	TLSModePSK
+)
+
+// TLSConfig configures the encrypted transport Sender.Send uses to reach
+// ServerAddress.
+type TLSConfig struct {
+	Mode TLSMode
+
+	// RootCAs, Certificates, and ServerName configure TLSModeCert the
+	// same way they would a crypto/tls.Config.
+	RootCAs      *x509.CertPool
+	Certificates []tls.Certificate
+	ServerName   string
+}
+
+// ErrPSKNotSupported is returned by Sender.Send when TLS.Mode is
+// TLSModePSK. See the TLSModePSK doc comment for why.
+var ErrPSKNotSupported = errors.New("zabbix: TLS-PSK is not supported by crypto/tls")
+
+// dialSender opens the connection Sender.Send writes the request packet
+// to, applying cfg's encryption mode if cfg is non-nil.
+func dialSender(addr string, timeout time.Duration, cfg *TLSConfig) (net.Conn, error) {
+	if cfg == nil || cfg.Mode == TLSModeUnencrypted {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+
+	if cfg.Mode == TLSModePSK {
+		return nil, ErrPSKNotSupported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dialer := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			RootCAs:      cfg.RootCAs,
+			Certificates: cfg.Certificates,
+			ServerName:   cfg.ServerName,
+		},
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
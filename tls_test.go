@@ -0,0 +1,109 @@
+package zabbix
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendOverTLSCert(t *testing.T) {
+	cert, pool := generateEphemeralCertForTest(t, "zabbix-server-test")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		tlsConn := tls.Server(conn, serverConfig)
+		defer tlsConn.Close()
+
+		req, err := readRequestPacketForTest(tlsConn)
+		if err != nil {
+			return
+		}
+		writeResponsePacketForTest(tlsConn, len(req.Data))
+	}()
+
+	sender := &Sender{
+		ServerAddress: ln.Addr().String(),
+		Timeout:       2 * time.Second,
+		TLS: &TLSConfig{
+			Mode:       TLSModeCert,
+			RootCAs:    pool,
+			ServerName: "zabbix-server-test",
+		},
+	}
+	resp, err := sender.Send([]TrapperData{{Host: "host1", Key: "key1", Value: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Total, 1; got != want {
+		t.Errorf("resp.Total mismatch, got=%v, want=%v", got, want)
+	}
+}
+
+func TestSendOverTLSPSKNotSupported(t *testing.T) {
+	sender := &Sender{
+		ServerAddress: "127.0.0.1:0",
+		Timeout:       time.Second,
+		TLS: &TLSConfig{
+			Mode: TLSModePSK,
+		},
+	}
+	_, err := sender.Send([]TrapperData{{Host: "host1", Key: "key1", Value: "1"}})
+	if err != ErrPSKNotSupported {
+		t.Errorf("err mismatch, got=%v, want=%v", err, ErrPSKNotSupported)
+	}
+}
+
+func generateEphemeralCertForTest(t *testing.T, commonName string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	cert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  priv,
+		Leaf:        leaf,
+	}
+	return cert, pool
+}